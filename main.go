@@ -2,12 +2,17 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"image"
 	"image/color"
+	stddraw "image/draw"
+	"image/gif"
 	_ "image/jpeg"
+	_ "image/png"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
@@ -16,11 +21,55 @@ import (
 	"github.com/hajimehoshi/ebiten/v2/inpututil"
 	"github.com/hajimehoshi/ebiten/v2/vector"
 	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
 )
 
+// supportedExts lists the local file extensions the slideshow will load from
+// photoDir, with or without --server (PhotoSync uses it too). The server normalizes every upload
+// to JPEG or GIF (see server/store/ingest.go), but files dropped straight
+// into the directory can be anything Go can decode.
+var supportedExts = map[string]bool{
+	".jpg":  true,
+	".jpeg": true,
+	".png":  true,
+	".gif":  true,
+	".webp": true,
+}
+
+func isSupportedImage(name string) bool {
+	return supportedExts[strings.ToLower(filepath.Ext(name))]
+}
+
+// Photo is one slideshow entry. Frames has a single entry for a still image;
+// an animated GIF has one entry per frame, each shown for its matching
+// Delays duration before advancing.
 type Photo struct {
-	Path string
-	Img  *ebiten.Image
+	Path   string
+	Frames []*ebiten.Image
+	Delays []time.Duration
+
+	frameIdx   int
+	frameSince time.Time
+}
+
+// currentFrame returns the frame that should be on screen right now.
+func (p *Photo) currentFrame() *ebiten.Image {
+	return p.Frames[p.frameIdx]
+}
+
+// advance moves an animated photo to its next frame once its current
+// frame's delay has elapsed. It's a no-op for still images.
+func (p *Photo) advance(now time.Time) {
+	if len(p.Frames) <= 1 {
+		return
+	}
+	if p.frameSince.IsZero() {
+		p.frameSince = now
+	}
+	if now.Sub(p.frameSince) >= p.Delays[p.frameIdx] {
+		p.frameIdx = (p.frameIdx + 1) % len(p.Frames)
+		p.frameSince = now
+	}
 }
 
 type Game struct {
@@ -160,6 +209,12 @@ func (g *Game) Update() error {
 		g.nextPhoto()
 	}
 
+	g.mu.Lock()
+	if len(g.photos) > 0 {
+		g.photos[g.currentIdx].advance(time.Now())
+	}
+	g.mu.Unlock()
+
 	if ebiten.IsFullscreen() {
 		ebiten.SetCursorMode(ebiten.CursorModeHidden)
 	} else {
@@ -198,7 +253,7 @@ func (g *Game) Draw(screen *ebiten.Image) {
 		g.mu.RUnlock()
 		return
 	}
-	img := g.photos[g.currentIdx].Img
+	img := g.photos[g.currentIdx].currentFrame()
 	g.mu.RUnlock()
 
 	imgWidth := img.Bounds().Dx()
@@ -231,17 +286,17 @@ func (g *Game) Layout(outsideWidth, outsideHeight int) (int, int) {
 	return outsideWidth, outsideHeight
 }
 
-func (g *Game) AddImage(path string, img *ebiten.Image) {
+func (g *Game) AddPhoto(photo *Photo) {
 	g.mu.Lock()
 	defer g.mu.Unlock()
 	// Check if image already exists
-	for _, photo := range g.photos {
-		if photo.Path == path {
+	for _, existing := range g.photos {
+		if existing.Path == photo.Path {
 			return // Image already exists
 		}
 	}
-	g.photos = append(g.photos, Photo{Path: path, Img: img})
-	fmt.Printf("Added image: %s\n", path)
+	g.photos = append(g.photos, *photo)
+	fmt.Printf("Added image: %s\n", photo.Path)
 }
 
 func (g *Game) RemoveImageByPath(path string) {
@@ -279,18 +334,18 @@ func loadImagesFromDir(dir string, game *Game) error {
 	semaphore := make(chan struct{}, 4)
 
 	for _, file := range files {
-		if filepath.Ext(file.Name()) == ".jpeg" || filepath.Ext(file.Name()) == ".jpg" {
+		if isSupportedImage(file.Name()) {
 			semaphore <- struct{}{}
 			go func(filename string) {
 				defer func() { <-semaphore }()
 
 				path := filepath.Join(dir, filename)
-				img, err := loadImage(path)
+				photo, err := loadPhoto(path)
 				if err != nil {
 					fmt.Printf("Failed to load image %s: %v\n", path, err)
 					return
 				}
-				game.AddImage(path, img)
+				game.AddPhoto(photo)
 			}(file.Name())
 		}
 	}
@@ -299,19 +354,11 @@ func loadImagesFromDir(dir string, game *Game) error {
 	return nil
 }
 
-func loadImage(path string) (*ebiten.Image, error) {
-	file, err := os.Open(path)
-	if err != nil {
-		return nil, err
-	}
-	defer file.Close()
-
-	img, _, err := image.Decode(file)
-	if err != nil {
-		return nil, err
-	}
+const maxWidth, maxHeight = 1920, 1080
 
-	const maxWidth, maxHeight = 1920, 1080
+// scaleToFit resizes img to fit within maxWidth x maxHeight, preserving
+// aspect ratio.
+func scaleToFit(img image.Image) *image.RGBA {
 	imgWidth := img.Bounds().Dx()
 	imgHeight := img.Bounds().Dy()
 
@@ -327,8 +374,77 @@ func loadImage(path string) (*ebiten.Image, error) {
 
 	dst := image.NewRGBA(image.Rect(0, 0, newWidth, newHeight))
 	draw.CatmullRom.Scale(dst, dst.Bounds(), img, img.Bounds(), draw.Over, nil)
+	return dst
+}
+
+// loadPhoto decodes the image at path. Animated GIFs become a Photo with one
+// Frame per GIF frame, each tagged with its declared display delay; every
+// other supported format becomes a single-frame Photo.
+func loadPhoto(path string) (*Photo, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	if strings.EqualFold(filepath.Ext(path), ".gif") {
+		frames, err := gif.DecodeAll(file)
+		if err != nil {
+			return nil, err
+		}
+		return photoFromGIF(path, frames), nil
+	}
+
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Photo{
+		Path:   path,
+		Frames: []*ebiten.Image{ebiten.NewImageFromImage(scaleToFit(img))},
+		Delays: []time.Duration{0},
+	}, nil
+}
+
+// photoFromGIF composites each frame of an animated GIF onto a running
+// canvas (GIF frames are often partial, relying on the previous frame still
+// being on screen) and converts the result to an ebiten.Image per frame.
+func photoFromGIF(path string, g *gif.GIF) *Photo {
+	bounds := image.Rect(0, 0, g.Config.Width, g.Config.Height)
+	canvas := image.NewRGBA(bounds)
+
+	frames := make([]*ebiten.Image, len(g.Image))
+	delays := make([]time.Duration, len(g.Image))
+	for i, frame := range g.Image {
+		stddraw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, stddraw.Over)
+
+		frameCopy := image.NewRGBA(bounds)
+		stddraw.Draw(frameCopy, bounds, canvas, bounds.Min, stddraw.Src)
+		frames[i] = ebiten.NewImageFromImage(scaleToFit(frameCopy))
+
+		delayHundredths := g.Delay[i]
+		if delayHundredths <= 0 {
+			delayHundredths = 10 // most viewers treat "0" as "as fast as reasonable", ~100ms
+		}
+		delays[i] = time.Duration(delayHundredths) * 10 * time.Millisecond
+	}
+
+	return &Photo{Path: path, Frames: frames, Delays: delays}
+}
 
-	return ebiten.NewImageFromImage(dst), nil
+// screenThumbWidth picks the long-edge thumbnail width to request from the
+// server, based on the primary monitor's resolution, falling back to a
+// generous default if monitor info isn't available this early.
+func screenThumbWidth() int {
+	if monitor := ebiten.Monitor(); monitor != nil {
+		w, h := monitor.Size()
+		if h > w {
+			return h
+		}
+		return w
+	}
+	return 1920
 }
 
 func watchDirectory(dir string, game *Game) {
@@ -356,19 +472,19 @@ func watchDirectory(dir string, game *Game) {
 			// Handle different event types
 			switch {
 			case event.Op&fsnotify.Create == fsnotify.Create:
-				if filepath.Ext(event.Name) == ".jpeg" || filepath.Ext(event.Name) == ".jpg" {
+				if isSupportedImage(event.Name) {
 					fmt.Printf("Detected new image: %s\n", event.Name)
 					go func(path string) {
-						img, err := loadImage(path)
+						photo, err := loadPhoto(path)
 						if err != nil {
 							fmt.Printf("Failed to load new image %s: %v\n", path, err)
 							return
 						}
-						game.AddImage(path, img)
+						game.AddPhoto(photo)
 					}(event.Name)
 				}
 			case event.Op&fsnotify.Remove == fsnotify.Remove, event.Op&fsnotify.Rename == fsnotify.Rename:
-				if filepath.Ext(event.Name) == ".jpeg" || filepath.Ext(event.Name) == ".jpg" {
+				if isSupportedImage(event.Name) {
 					fmt.Printf("Detected removed image: %s\n", event.Name)
 					game.RemoveImageByPath(event.Name)
 				}
@@ -383,6 +499,13 @@ func watchDirectory(dir string, game *Game) {
 }
 
 func main() {
+	var (
+		serverURL = flag.String("server", "", "goframe server URL to sync photos from (e.g. http://host:8080)")
+		token     = flag.String("token", "", "Bearer token for the goframe server")
+		album     = flag.String("album", "default", "Album to sync")
+	)
+	flag.Parse()
+
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
 		fmt.Printf("Failed to get user home directory: %v\n", err)
@@ -415,6 +538,17 @@ func main() {
 	// Start directory watcher
 	go watchDirectory(dir, game)
 
+	// Sync with the goframe server, if configured. New and removed photos
+	// are picked up by the directory watcher above as soon as PhotoSync
+	// writes or deletes them. WatchEvents keeps a push channel open so
+	// changes land immediately, and runs a full Sync on every (re)connect.
+	if *serverURL != "" {
+		photoSync := NewPhotoSync(*serverURL, dir, *token, *album, screenThumbWidth(), func() {
+			fmt.Println("Sync brought in changes")
+		})
+		go photoSync.WatchEvents(nil)
+	}
+
 	ebiten.SetWindowResizingMode(ebiten.WindowResizingModeEnabled)
 	ebiten.SetWindowSize(800, 600)
 	ebiten.SetWindowTitle("Photo Frame")
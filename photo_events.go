@@ -0,0 +1,119 @@
+// photo_events.go
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// PhotoEvent mirrors the JSON frames the server streams over /photos/events.
+type PhotoEvent struct {
+	Type       string `json:"type"` // "added" or "deleted"
+	Album      string `json:"album"`
+	Hash       string `json:"hash"`
+	Filename   string `json:"filename"`
+	MimeType   string `json:"mime_type,omitempty"`
+	FrameCount int    `json:"frame_count,omitempty"`
+}
+
+// WatchEvents keeps a WebSocket connection to the server's event stream
+// alive, applying each add/delete as it arrives. It reconnects with the same
+// exponential backoff syncViaList uses (doubling from one minute, capped at
+// an hour), and runs a full Sync on every (re)connect to reconcile anything
+// missed while disconnected. It blocks until stop is closed.
+func (ps *PhotoSync) WatchEvents(stop <-chan struct{}) {
+	for {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+
+		if err := ps.watchEventsOnce(stop); err != nil {
+			fmt.Printf("Event stream failed: %v\n", err)
+			ps.retryBackoff *= 2
+			if ps.retryBackoff > 1*time.Hour {
+				ps.retryBackoff = 1 * time.Hour
+			}
+		} else {
+			ps.retryBackoff = 1 * time.Minute
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-time.After(ps.retryBackoff):
+		}
+	}
+}
+
+func (ps *PhotoSync) eventsURL() string {
+	url := ps.albumURL("events")
+	url = strings.Replace(url, "http://", "ws://", 1)
+	url = strings.Replace(url, "https://", "wss://", 1)
+	return url
+}
+
+func (ps *PhotoSync) watchEventsOnce(stop <-chan struct{}) error {
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+ps.token)
+
+	conn, _, err := websocket.DefaultDialer.Dial(ps.eventsURL(), header)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	fmt.Println("Connected to event stream, reconciling with a full sync...")
+	if err := ps.Sync(); err != nil {
+		fmt.Printf("Reconcile sync failed: %v\n", err)
+	}
+
+	for {
+		select {
+		case <-stop:
+			return nil
+		default:
+		}
+
+		var event PhotoEvent
+		if err := conn.ReadJSON(&event); err != nil {
+			return err
+		}
+		ps.applyEvent(event)
+	}
+}
+
+func (ps *PhotoSync) applyEvent(event PhotoEvent) {
+	switch event.Type {
+	case "added":
+		fmt.Printf("Event: %s added (%s)\n", event.Filename, event.Hash[:8])
+		photo := PhotoMetadata{
+			Hash:       event.Hash,
+			Filename:   event.Filename,
+			MimeType:   event.MimeType,
+			FrameCount: event.FrameCount,
+		}
+		if err := ps.downloadPhoto(photo); err != nil {
+			fmt.Printf("Error downloading %s: %v\n", event.Filename, err)
+			return
+		}
+	case "deleted":
+		fmt.Printf("Event: %s deleted\n", event.Hash[:8])
+		if err := ps.deleteLocalPhoto(event.Hash); err != nil {
+			fmt.Printf("Error deleting %s: %v\n", event.Hash[:8], err)
+			return
+		}
+	default:
+		fmt.Printf("Unknown event type: %s\n", event.Type)
+		return
+	}
+
+	if ps.onSyncComplete != nil {
+		ps.onSyncComplete()
+	}
+}
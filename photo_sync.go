@@ -15,34 +15,63 @@ import (
 )
 
 type PhotoMetadata struct {
-	Hash      string    `json:"hash"`
-	Filename  string    `json:"filename"`
-	UpdatedAt time.Time `json:"updated_at"`
+	Hash       string    `json:"hash"`
+	Filename   string    `json:"filename"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	MimeType   string    `json:"mime_type"`
+	FrameCount int       `json:"frame_count"`
 }
 
 type PhotoSync struct {
-	serverURL      string
-	photoDir       string
-	localHashes    map[string]bool
-	client         *http.Client
-	lastError      error
-	retryBackoff   time.Duration
-	syncMutex      sync.Mutex
-	isSyncing      bool
-	onSyncComplete func()
+	serverURL        string
+	photoDir         string
+	token            string
+	album            string
+	thumbWidth       int
+	localHashes      map[string]string // hash -> local path
+	client           *http.Client
+	lastError        error
+	retryBackoff     time.Duration
+	syncMutex        sync.Mutex
+	isSyncing        bool
+	onSyncComplete   func()
+	deltaUnsupported bool // set once the server 404s /sync, so we stop retrying it every cycle
 }
 
-func NewPhotoSync(serverURL, photoDir string, onSyncComplete func()) *PhotoSync {
+// NewPhotoSync creates a PhotoSync that mirrors album from serverURL into
+// photoDir. thumbWidth is the long-edge size requested for downloaded
+// photos; pass the frame's screen width so downloads are sized for display
+// instead of pulling full-resolution originals.
+func NewPhotoSync(serverURL, photoDir, token, album string, thumbWidth int, onSyncComplete func()) *PhotoSync {
 	return &PhotoSync{
 		serverURL:      serverURL,
 		photoDir:       photoDir,
-		localHashes:    make(map[string]bool),
+		token:          token,
+		album:          album,
+		thumbWidth:     thumbWidth,
+		localHashes:    make(map[string]string),
 		client:         &http.Client{Timeout: 30 * time.Second},
 		retryBackoff:   1 * time.Minute,
 		onSyncComplete: onSyncComplete,
 	}
 }
 
+// albumURL builds a URL under this sync's album, e.g. "list" becomes
+// "<serverURL>/albums/<album>/photos/list".
+func (ps *PhotoSync) albumURL(suffix string) string {
+	return fmt.Sprintf("%s/albums/%s/photos/%s", ps.serverURL, ps.album, suffix)
+}
+
+// newRequest builds an authenticated request for the sync's server.
+func (ps *PhotoSync) newRequest(method, url string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+ps.token)
+	return req, nil
+}
+
 func (ps *PhotoSync) hashFile(path string) (string, error) {
 	f, err := os.Open(path)
 	if err != nil {
@@ -58,26 +87,102 @@ func (ps *PhotoSync) hashFile(path string) (string, error) {
 	return hex.EncodeToString(h.Sum(nil)), nil
 }
 
+// hashIndexFile records, per local file, the server hash it was downloaded
+// under. downloadPhoto writes this after every download. When thumbWidth is
+// set (the common case), a local file's bytes are a resized thumbnail and no
+// longer hash to the server's value; loadLocalHashes trusts this record instead of
+// rehashing, so a resynced thumbnail doesn't look like a different photo.
+const hashIndexFile = ".goframe-hashes.json"
+
+func (ps *PhotoSync) hashIndexPath() string {
+	return filepath.Join(ps.photoDir, hashIndexFile)
+}
+
+// loadHashIndex reads the path -> hash index, returning an empty map if it
+// doesn't exist yet or is unreadable (a corrupt index just means every file
+// gets rehashed this once, same as before this index existed).
+func (ps *PhotoSync) loadHashIndex() map[string]string {
+	data, err := os.ReadFile(ps.hashIndexPath())
+	if err != nil {
+		return make(map[string]string)
+	}
+	index := make(map[string]string)
+	if err := json.Unmarshal(data, &index); err != nil {
+		return make(map[string]string)
+	}
+	return index
+}
+
+// saveHashIndex persists path -> hash for every entry currently in
+// localHashes.
+func (ps *PhotoSync) saveHashIndex() error {
+	index := make(map[string]string, len(ps.localHashes))
+	for hash, path := range ps.localHashes {
+		index[path] = hash
+	}
+	data, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(ps.hashIndexPath(), data, 0644)
+}
+
+// loadLocalHashes rebuilds localHashes from the on-disk hash index rather
+// than rehashing file contents, since a locally-stored photo may be a
+// resized thumbnail (see downloadPhoto) whose bytes never match the hash it
+// was downloaded under. Files present on disk but missing from the index —
+// dropped in manually, or written before this index existed — are hashed
+// once so they're still tracked, and folded into the index for next time.
 func (ps *PhotoSync) loadLocalHashes() error {
 	files, err := os.ReadDir(ps.photoDir)
 	if err != nil {
 		return err
 	}
 
-	ps.localHashes = make(map[string]bool)
+	pathToHash := ps.loadHashIndex()
+
+	ps.localHashes = make(map[string]string)
+	dirty := false
 	for _, file := range files {
-		if filepath.Ext(file.Name()) == ".jpeg" {
-			hash, err := ps.hashFile(filepath.Join(ps.photoDir, file.Name()))
-			if err != nil {
-				continue
-			}
-			ps.localHashes[hash] = true
+		if !isSupportedImage(file.Name()) {
+			continue
 		}
+		path := filepath.Join(ps.photoDir, file.Name())
+		if hash, ok := pathToHash[path]; ok {
+			ps.localHashes[hash] = path
+			continue
+		}
+		hash, err := ps.hashFile(path)
+		if err != nil {
+			continue
+		}
+		ps.localHashes[hash] = path
+		dirty = true
+	}
+
+	if dirty {
+		return ps.saveHashIndex()
 	}
 	return nil
 }
 
+// Sync brings photoDir up to date with the server's album. It prefers the
+// delta endpoint (see photo_sync_delta.go), which does it in one request,
+// and falls back to the list-then-GET-per-photo approach in syncViaList
+// against older servers that don't have it.
 func (ps *PhotoSync) Sync() error {
+	if !ps.deltaUnsupported {
+		supported, err := ps.syncDelta()
+		if supported {
+			return err
+		}
+		fmt.Println("Server doesn't support delta sync, falling back to full list sync")
+		ps.deltaUnsupported = true
+	}
+	return ps.syncViaList()
+}
+
+func (ps *PhotoSync) syncViaList() error {
 	startTime := time.Now()
 	fmt.Println("Starting photo sync...")
 
@@ -88,7 +193,11 @@ func (ps *PhotoSync) Sync() error {
 	defer ps.syncMutex.Unlock()
 
 	fmt.Printf("Fetching photo list from %s...\n", ps.serverURL)
-	resp, err := ps.client.Get(ps.serverURL + "/photos/list")
+	req, err := ps.newRequest(http.MethodGet, ps.albumURL("list"), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := ps.client.Do(req)
 	if err != nil {
 		ps.lastError = fmt.Errorf("server connection failed: %v", err)
 		ps.retryBackoff *= 2
@@ -142,7 +251,7 @@ func (ps *PhotoSync) Sync() error {
 	downloadCount := 0
 	fmt.Println("Checking for new photos to download...")
 	for _, remote := range remotePhotos {
-		if !ps.localHashes[remote.Hash] {
+		if _, exists := ps.localHashes[remote.Hash]; !exists {
 			fmt.Printf("Downloading %s (%s)...\n", remote.Filename, remote.Hash[:8])
 			if err := ps.downloadPhoto(remote); err != nil {
 				fmt.Printf("Error downloading photo %s: %v\n", remote.Hash[:8], err)
@@ -166,27 +275,56 @@ func (ps *PhotoSync) Sync() error {
 	return nil
 }
 
+// deleteLocalPhoto removes hash's local file. It looks up the path in
+// localHashes instead of rehashing every file in photoDir; if hash isn't in
+// localHashes (e.g. an event arrives before the first Sync), it falls back
+// to a directory walk that rehashes each file.
 func (ps *PhotoSync) deleteLocalPhoto(hash string) error {
-	files, err := os.ReadDir(ps.photoDir)
-	if err != nil {
-		return err
-	}
-
-	for _, file := range files {
-		path := filepath.Join(ps.photoDir, file.Name())
-		fileHash, err := ps.hashFile(path)
+	path, ok := ps.localHashes[hash]
+	if !ok {
+		files, err := os.ReadDir(ps.photoDir)
 		if err != nil {
-			continue
+			return err
 		}
-		if fileHash == hash {
-			return os.Remove(path)
+		for _, file := range files {
+			candidate := filepath.Join(ps.photoDir, file.Name())
+			fileHash, err := ps.hashFile(candidate)
+			if err != nil {
+				continue
+			}
+			if fileHash == hash {
+				path = candidate
+				ok = true
+				break
+			}
 		}
 	}
-	return nil
+	if !ok {
+		return nil
+	}
+
+	if err := os.Remove(path); err != nil {
+		return err
+	}
+	delete(ps.localHashes, hash)
+	return ps.saveHashIndex()
 }
 
 func (ps *PhotoSync) downloadPhoto(photo PhotoMetadata) error {
-	resp, err := ps.client.Get(fmt.Sprintf("%s/photos/%s", ps.serverURL, photo.Hash))
+	url := ps.albumURL(photo.Hash)
+	// The thumbnail endpoint always re-encodes to a still JPEG (see
+	// server/store/thumbnail.go), so an animated photo has to be fetched in
+	// full or it would be written under its original extension with JPEG
+	// bytes inside.
+	if ps.thumbWidth > 0 && photo.FrameCount <= 1 {
+		url = fmt.Sprintf("%s/thumb?w=%d", ps.albumURL(photo.Hash), ps.thumbWidth)
+	}
+
+	req, err := ps.newRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := ps.client.Do(req)
 	if err != nil {
 		return err
 	}
@@ -199,6 +337,13 @@ func (ps *PhotoSync) downloadPhoto(photo PhotoMetadata) error {
 	}
 	defer f.Close()
 
-	_, err = io.Copy(f, resp.Body)
-	return err
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		return err
+	}
+
+	if ps.localHashes == nil {
+		ps.localHashes = make(map[string]string)
+	}
+	ps.localHashes[photo.Hash] = path
+	return ps.saveHashIndex()
 }
@@ -0,0 +1,165 @@
+// photo_sync_delta.go
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// syncFrameHeader mirrors store.SyncFrameHeader, the per-photo header the
+// server writes before each photo's bytes in a delta sync response.
+type syncFrameHeader struct {
+	Hash       string `json:"hash"`
+	Filename   string `json:"filename"`
+	MimeType   string `json:"mime_type"`
+	FrameCount int    `json:"frame_count"`
+	Length     int64  `json:"length"`
+}
+
+// syncDelta asks the server for only what's changed since the last sync by
+// posting the hashes we already have to /sync, instead of Sync's GET-list-
+// then-GET-per-photo. The returned bool reports whether the server supports
+// the endpoint at all; callers should fall back to syncViaList when it's
+// false (err is nil in that case - there's nothing to report).
+func (ps *PhotoSync) syncDelta() (supported bool, err error) {
+	if !ps.syncMutex.TryLock() {
+		fmt.Println("Sync already in progress, skipping")
+		return true, fmt.Errorf("sync already in progress")
+	}
+	defer ps.syncMutex.Unlock()
+
+	if err := ps.loadLocalHashes(); err != nil {
+		return true, err
+	}
+
+	known := make([]string, 0, len(ps.localHashes))
+	for hash := range ps.localHashes {
+		known = append(known, hash)
+	}
+
+	body, err := json.Marshal(known)
+	if err != nil {
+		return true, err
+	}
+
+	req, err := ps.newRequest(http.MethodPost, ps.albumURL("sync"), bytes.NewReader(body))
+	if err != nil {
+		return true, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := ps.client.Do(req)
+	if err != nil {
+		ps.lastError = fmt.Errorf("server connection failed: %v", err)
+		return true, ps.lastError
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		ps.lastError = fmt.Errorf("sync request failed: %s", resp.Status)
+		return true, ps.lastError
+	}
+	ps.lastError = nil
+
+	downloaded, deleted, err := ps.applySyncDelta(resp.Body)
+	if err != nil {
+		return true, err
+	}
+
+	fmt.Printf("Delta sync complete: %d downloaded, %d deleted\n", downloaded, deleted)
+	if (downloaded > 0 || deleted > 0) && ps.onSyncComplete != nil {
+		ps.onSyncComplete()
+	}
+	return true, nil
+}
+
+// applySyncDelta reads a store.WriteSyncDelta stream: zero or more length-
+// prefixed (header, photo bytes) frames terminated by a zero-length header,
+// followed by a length-prefixed JSON array of hashes to delete.
+func (ps *PhotoSync) applySyncDelta(r io.Reader) (downloaded, deleted int, err error) {
+	dec, err := zstd.NewReader(r)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer dec.Close()
+
+	for {
+		headerLen, err := readFrameLen(dec)
+		if err != nil {
+			return downloaded, deleted, err
+		}
+		if headerLen == 0 {
+			break
+		}
+
+		headerBytes := make([]byte, headerLen)
+		if _, err := io.ReadFull(dec, headerBytes); err != nil {
+			return downloaded, deleted, err
+		}
+		var header syncFrameHeader
+		if err := json.Unmarshal(headerBytes, &header); err != nil {
+			return downloaded, deleted, err
+		}
+
+		path := filepath.Join(ps.photoDir, header.Filename)
+		f, err := os.Create(path)
+		if err != nil {
+			return downloaded, deleted, err
+		}
+		_, copyErr := io.CopyN(f, dec, header.Length)
+		f.Close()
+		if copyErr != nil {
+			return downloaded, deleted, copyErr
+		}
+
+		ps.localHashes[header.Hash] = path
+		downloaded++
+	}
+
+	if downloaded > 0 {
+		if err := ps.saveHashIndex(); err != nil {
+			return downloaded, deleted, err
+		}
+	}
+
+	deleteLen, err := readFrameLen(dec)
+	if err != nil {
+		return downloaded, deleted, err
+	}
+	deleteBytes := make([]byte, deleteLen)
+	if _, err := io.ReadFull(dec, deleteBytes); err != nil {
+		return downloaded, deleted, err
+	}
+	var toDelete []string
+	if err := json.Unmarshal(deleteBytes, &toDelete); err != nil {
+		return downloaded, deleted, err
+	}
+	for _, hash := range toDelete {
+		if err := ps.deleteLocalPhoto(hash); err != nil {
+			fmt.Printf("Error deleting photo %s: %v\n", hash[:8], err)
+			continue
+		}
+		deleted++
+	}
+
+	return downloaded, deleted, nil
+}
+
+func readFrameLen(r io.Reader) (uint32, error) {
+	var buf [4]byte
+	if _, err := io.ReadFull(r, buf[:]); err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint32(buf[:]), nil
+}
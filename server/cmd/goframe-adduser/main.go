@@ -0,0 +1,54 @@
+// goframe-adduser creates users and grants them album access in a goframe
+// catalog without requiring direct SQL access.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/OpticalFlyer/goframe/server/store"
+)
+
+func main() {
+	var (
+		photoDir = flag.String("photos", "", "Photo storage directory (same value passed to the server)")
+		username = flag.String("user", "", "Username to create")
+		password = flag.String("password", "", "Password for the new user")
+		album    = flag.String("album", "default", "Album to grant access to")
+		perm     = flag.String("permission", string(store.PermRead), "Permission to grant: read, upload, or admin")
+	)
+	flag.Parse()
+
+	if *username == "" || *password == "" {
+		fmt.Fprintln(os.Stderr, "usage: goframe-adduser -user <name> -password <pass> [-album <album>] [-permission read|upload|admin]")
+		os.Exit(2)
+	}
+
+	if *photoDir == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			log.Fatal(err)
+		}
+		*photoDir = filepath.Join(homeDir, ".goframe", "photos")
+	}
+
+	photos, err := store.NewPhotoStorage(*photoDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	token, err := photos.CreateUser(*username, *password)
+	if err != nil {
+		log.Fatalf("failed to create user: %v", err)
+	}
+
+	if err := photos.GrantAccess(*username, *album, store.Permission(*perm)); err != nil {
+		log.Fatalf("failed to grant access: %v", err)
+	}
+
+	fmt.Printf("Created user %q with access (%s) to album %q\n", *username, *perm, *album)
+	fmt.Printf("Token: %s\n", token)
+}
@@ -0,0 +1,48 @@
+// events.go
+package main
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gorilla/websocket"
+)
+
+var eventUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	// Photo frames are LAN/companion-app clients, not browsers, so the
+	// default same-origin check has nothing useful to enforce here.
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// handleEvents upgrades the connection to a WebSocket and streams every
+// photo added to or deleted from album until the client disconnects.
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request, album string) {
+	conn, err := eventUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("events: upgrade failed: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	events, unsubscribe := s.photos.Subscribe(album)
+	defer unsubscribe()
+
+	// Drain client reads so we notice a closed connection; the frame never
+	// sends anything to us.
+	go func() {
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				conn.Close()
+				return
+			}
+		}
+	}()
+
+	for event := range events {
+		if err := conn.WriteJSON(event); err != nil {
+			return
+		}
+	}
+}
@@ -1,185 +1,164 @@
 package main
 
 import (
-	"crypto/sha256"
-	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
 	"os"
 	"path/filepath"
-	"sync"
-	"time"
+	"strconv"
+	"strings"
+
+	"github.com/OpticalFlyer/goframe/server/store"
 )
 
-type PhotoMetadata struct {
-	Hash      string    `json:"hash"`
-	Filename  string    `json:"filename"`
-	UpdatedAt time.Time `json:"updated_at"`
+type Server struct {
+	photos *store.PhotoStorage
 }
 
-type PhotoStorage struct {
-	baseDir string
-	mu      sync.RWMutex
-	photos  map[string]PhotoMetadata
+// parseAlbumPath splits a "/albums/{album}/photos[/{suffix}]" path into its
+// album name and the part after "/photos". ok is false for any other shape,
+// including an album that fails store.ValidAlbum — callers build on-disk
+// paths from album, so a malformed one (e.g. containing "/" or "..") must
+// never reach them.
+func parseAlbumPath(path string) (album, suffix string, ok bool) {
+	const prefix = "/albums/"
+	if !strings.HasPrefix(path, prefix) {
+		return "", "", false
+	}
+	parts := strings.SplitN(strings.TrimPrefix(path, prefix), "/photos", 2)
+	if len(parts) != 2 || parts[0] == "" || !store.ValidAlbum(parts[0]) {
+		return "", "", false
+	}
+	return parts[0], strings.TrimPrefix(parts[1], "/"), true
 }
 
-func NewPhotoStorage(baseDir string) (*PhotoStorage, error) {
-	if err := os.MkdirAll(baseDir, 0755); err != nil {
-		return nil, err
-	}
-
-	ps := &PhotoStorage{
-		baseDir: baseDir,
-		photos:  make(map[string]PhotoMetadata),
+// apiHandler authenticates the request (bearer token or HTTP Basic) against
+// the user table, checks that the caller holds at least the permission the
+// method requires on the album named in the path, and dispatches to the
+// handler for the path's suffix (list, sync, events, thumb, or a photo hash)
+// with a *User in the request context.
+func (s *Server) apiHandler(w http.ResponseWriter, r *http.Request) {
+	album, suffix, ok := parseAlbumPath(r.URL.Path)
+	if !ok {
+		http.NotFound(w, r)
+		return
 	}
 
-	return ps, ps.loadExistingPhotos()
-}
-
-func (ps *PhotoStorage) loadExistingPhotos() error {
-	ps.mu.Lock()
-	defer ps.mu.Unlock()
-
-	return filepath.Walk(ps.baseDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-		if !info.IsDir() && filepath.Ext(path) == ".jpeg" {
-			hash, err := ps.hashFile(path)
-			if err != nil {
-				return err
-			}
-			ps.photos[hash] = PhotoMetadata{
-				Hash:      hash,
-				Filename:  info.Name(),
-				UpdatedAt: info.ModTime(),
-			}
-		}
-		return nil
-	})
-}
-
-func (ps *PhotoStorage) hashFile(path string) (string, error) {
-	f, err := os.Open(path)
+	user, err := s.photos.Authenticate(r)
 	if err != nil {
-		return "", err
+		w.Header().Set("WWW-Authenticate", `Basic realm="goframe"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
 	}
-	defer f.Close()
 
-	h := sha256.New()
-	if _, err := io.Copy(h, f); err != nil {
-		return "", err
+	required := store.PermRead
+	switch {
+	case suffix == "sync":
+		required = store.PermRead // posts a hash list, but only to read back what's missing
+	case r.Method == http.MethodPost:
+		required = store.PermUpload
+	case r.Method == http.MethodDelete:
+		required = store.PermAdmin
 	}
 
-	return hex.EncodeToString(h.Sum(nil)), nil
-}
-
-func (ps *PhotoStorage) List() []PhotoMetadata {
-	ps.mu.RLock()
-	defer ps.mu.RUnlock()
-
-	list := make([]PhotoMetadata, 0, len(ps.photos))
-	for _, photo := range ps.photos {
-		list = append(list, photo)
+	granted, err := s.photos.PermissionFor(user.ID, album)
+	if err != nil || !granted.Allows(required) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
 	}
-	return list
-}
 
-func (ps *PhotoStorage) Get(hash string) (string, error) {
-	ps.mu.RLock()
-	photo, exists := ps.photos[hash]
-	ps.mu.RUnlock()
+	r = r.WithContext(store.ContextWithUser(r.Context(), user))
 
-	if !exists {
-		return "", os.ErrNotExist
+	switch {
+	case suffix == "list":
+		s.handleList(w, r, album)
+	case suffix == "sync":
+		s.handleSync(w, r, album)
+	case suffix == "events":
+		s.handleEvents(w, r, album)
+	case strings.HasSuffix(suffix, "/thumb"):
+		hash := strings.TrimSuffix(suffix, "/thumb")
+		if !store.ValidHash(hash) {
+			http.NotFound(w, r)
+			return
+		}
+		s.handleThumbnail(w, r, album, hash)
+	default:
+		if !store.ValidHash(suffix) {
+			http.NotFound(w, r)
+			return
+		}
+		s.handlePhoto(w, r, album, suffix)
 	}
-
-	return filepath.Join(ps.baseDir, photo.Filename), nil
 }
 
-func (ps *PhotoStorage) Add(filename string, reader io.Reader) (*PhotoMetadata, error) {
-	tempPath := filepath.Join(ps.baseDir, "tmp-"+filename)
-	f, err := os.Create(tempPath)
-	if err != nil {
-		return nil, err
-	}
-
-	h := sha256.New()
-	if _, err := io.Copy(io.MultiWriter(f, h), reader); err != nil {
-		f.Close()
-		os.Remove(tempPath)
-		return nil, err
+func (s *Server) handleList(w http.ResponseWriter, r *http.Request, album string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
 	}
-	f.Close()
-
-	hash := hex.EncodeToString(h.Sum(nil))
-	finalPath := filepath.Join(ps.baseDir, filename)
 
-	if err := os.Rename(tempPath, finalPath); err != nil {
-		os.Remove(tempPath)
-		return nil, err
+	var similarTo *int64
+	maxDistance := store.DuplicateDistance
+	if q := r.URL.Query().Get("similar_to"); q != "" {
+		phash, err := strconv.ParseInt(q, 10, 64)
+		if err != nil {
+			http.Error(w, "invalid similar_to", http.StatusBadRequest)
+			return
+		}
+		similarTo = &phash
+		if q := r.URL.Query().Get("max_distance"); q != "" {
+			maxDistance, err = strconv.Atoi(q)
+			if err != nil {
+				http.Error(w, "invalid max_distance", http.StatusBadRequest)
+				return
+			}
+		}
 	}
 
-	info, err := os.Stat(finalPath)
+	photos, err := s.photos.List(album, similarTo, maxDistance)
 	if err != nil {
-		return nil, err
-	}
-
-	meta := PhotoMetadata{
-		Hash:      hash,
-		Filename:  filename,
-		UpdatedAt: info.ModTime(),
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
-
-	ps.mu.Lock()
-	ps.photos[hash] = meta
-	ps.mu.Unlock()
-
-	return &meta, nil
+	json.NewEncoder(w).Encode(photos)
 }
 
-func (ps *PhotoStorage) Delete(hash string) error {
-	ps.mu.Lock()
-	defer ps.mu.Unlock()
-
-	photo, exists := ps.photos[hash]
-	if !exists {
-		return os.ErrNotExist
+// handleThumbnail serves a cached thumbnail of hash's photo, generating it
+// on first request. The "w" query parameter requests a long-edge width and
+// snaps up to the nearest stock size (see store.Thumbnail).
+func (s *Server) handleThumbnail(w http.ResponseWriter, r *http.Request, album, hash string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
 	}
 
-	path := filepath.Join(ps.baseDir, photo.Filename)
-	if err := os.Remove(path); err != nil {
-		return err
+	width := 256
+	if q := r.URL.Query().Get("w"); q != "" {
+		parsed, err := strconv.Atoi(q)
+		if err != nil || parsed <= 0 {
+			http.Error(w, "invalid w", http.StatusBadRequest)
+			return
+		}
+		width = parsed
 	}
 
-	delete(ps.photos, hash)
-	return nil
-}
-
-type Server struct {
-	photos *PhotoStorage
-}
-
-func (s *Server) handleList(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	path, err := s.photos.Thumbnail(album, hash, width)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
 		return
 	}
-
-	photos := s.photos.List()
-	json.NewEncoder(w).Encode(photos)
+	http.ServeFile(w, r, path)
 }
 
-func (s *Server) handlePhoto(w http.ResponseWriter, r *http.Request) {
-	hash := filepath.Base(r.URL.Path)
-
+func (s *Server) handlePhoto(w http.ResponseWriter, r *http.Request, album, hash string) {
 	switch r.Method {
 	case http.MethodGet:
-		path, err := s.photos.Get(hash)
+		path, err := s.photos.Get(album, hash)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusNotFound)
 			return
@@ -194,8 +173,15 @@ func (s *Server) handlePhoto(w http.ResponseWriter, r *http.Request) {
 		}
 		defer file.Close()
 
-		meta, err := s.photos.Add(header.Filename, file)
+		meta, err := s.photos.Add(album, header.Filename, file)
 		if err != nil {
+			var dup *store.DuplicateError
+			if errors.As(err, &dup) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusConflict)
+				json.NewEncoder(w).Encode(dup.Existing)
+				return
+			}
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
@@ -204,7 +190,7 @@ func (s *Server) handlePhoto(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(meta)
 
 	case http.MethodDelete:
-		if err := s.photos.Delete(hash); err != nil {
+		if err := s.photos.Delete(album, hash); err != nil {
 			http.Error(w, err.Error(), http.StatusNotFound)
 			return
 		}
@@ -230,7 +216,7 @@ func main() {
 		*photoDir = filepath.Join(homeDir, ".goframe", "photos")
 	}
 
-	photos, err := NewPhotoStorage(*photoDir)
+	photos, err := store.NewPhotoStorage(*photoDir)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -238,8 +224,7 @@ func main() {
 	server := &Server{photos: photos}
 
 	mux := http.NewServeMux()
-	mux.HandleFunc("/photos/list", server.handleList)
-	mux.HandleFunc("/photos/", server.handlePhoto)
+	mux.HandleFunc("/albums/", server.apiHandler)
 
 	addr := fmt.Sprintf(":%s", *port)
 	log.Printf("Starting server on %s", addr)
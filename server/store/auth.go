@@ -0,0 +1,177 @@
+// auth.go
+package store
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// User identifies the caller of an authenticated request.
+type User struct {
+	ID       int64
+	Username string
+}
+
+// Permission is the access level a user holds on an album.
+type Permission string
+
+const (
+	PermRead   Permission = "read"
+	PermUpload Permission = "upload"
+	PermAdmin  Permission = "admin"
+)
+
+// permissionRank orders permissions from least to most privileged so Allows
+// can treat a higher grant as satisfying a lower requirement.
+var permissionRank = map[Permission]int{
+	PermRead:   1,
+	PermUpload: 2,
+	PermAdmin:  3,
+}
+
+// Allows reports whether a user holding p may perform an action that
+// requires at least required.
+func (p Permission) Allows(required Permission) bool {
+	return permissionRank[p] >= permissionRank[required]
+}
+
+var (
+	errInvalidCredentials = errors.New("invalid credentials")
+	errNoAccess           = errors.New("no access to album")
+)
+
+// userContextKey is the context key under which apiHandler stores the
+// authenticated *User.
+type userContextKey struct{}
+
+// ContextWithUser returns a context carrying user for later retrieval by
+// UserFromContext.
+func ContextWithUser(ctx context.Context, user *User) context.Context {
+	return context.WithValue(ctx, userContextKey{}, user)
+}
+
+// UserFromContext returns the user injected by apiHandler, if any.
+func UserFromContext(ctx context.Context) (*User, bool) {
+	u, ok := ctx.Value(userContextKey{}).(*User)
+	return u, ok
+}
+
+// CreateUser hashes password with bcrypt and stores a new user with a random
+// bearer token, returning the token so it can be handed to the operator once.
+func (ps *PhotoStorage) CreateUser(username, password string) (token string, err error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+
+	token, err = generateToken()
+	if err != nil {
+		return "", err
+	}
+
+	_, err = ps.db.Exec(
+		`INSERT INTO users (username, password_hash, token) VALUES (?, ?, ?)`,
+		username, string(hash), token)
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+func generateToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// GrantAccess gives username permission on album, replacing any existing
+// grant for that pair.
+func (ps *PhotoStorage) GrantAccess(username, album string, permission Permission) error {
+	var userID int64
+	if err := ps.db.QueryRow(`SELECT id FROM users WHERE username = ?`, username).Scan(&userID); err != nil {
+		if err == sql.ErrNoRows {
+			return errInvalidCredentials
+		}
+		return err
+	}
+
+	_, err := ps.db.Exec(
+		`INSERT INTO album_acls (user_id, album, permission) VALUES (?, ?, ?)
+		 ON CONFLICT(user_id, album) DO UPDATE SET permission = excluded.permission`,
+		userID, album, string(permission))
+	return err
+}
+
+// Authenticate resolves a bearer token or HTTP Basic auth header to a User.
+func (ps *PhotoStorage) Authenticate(r *http.Request) (*User, error) {
+	if token, ok := bearerToken(r); ok {
+		return ps.userByToken(token)
+	}
+	if username, password, ok := r.BasicAuth(); ok {
+		return ps.userByPassword(username, password)
+	}
+	return nil, errInvalidCredentials
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	auth := r.Header.Get("Authorization")
+	const prefix = "Bearer "
+	if !strings.HasPrefix(auth, prefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(auth, prefix), true
+}
+
+func (ps *PhotoStorage) userByToken(token string) (*User, error) {
+	var u User
+	err := ps.db.QueryRow(`SELECT id, username FROM users WHERE token = ?`, token).Scan(&u.ID, &u.Username)
+	if err == sql.ErrNoRows {
+		return nil, errInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+func (ps *PhotoStorage) userByPassword(username, password string) (*User, error) {
+	var u User
+	var hash string
+	err := ps.db.QueryRow(`SELECT id, username, password_hash FROM users WHERE username = ?`, username).
+		Scan(&u.ID, &u.Username, &hash)
+	if err == sql.ErrNoRows {
+		return nil, errInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil {
+		return nil, errInvalidCredentials
+	}
+	return &u, nil
+}
+
+// PermissionFor returns the permission userID holds on album.
+func (ps *PhotoStorage) PermissionFor(userID int64, album string) (Permission, error) {
+	var permission string
+	err := ps.db.QueryRow(
+		`SELECT permission FROM album_acls WHERE user_id = ? AND album = ?`, userID, album,
+	).Scan(&permission)
+	if err == sql.ErrNoRows {
+		return "", errNoAccess
+	}
+	if err != nil {
+		return "", err
+	}
+	return Permission(permission), nil
+}
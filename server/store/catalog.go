@@ -0,0 +1,393 @@
+// catalog.go
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	_ "image/gif" // stored GIFs are kept as-is (see normalizeUpload), so thumbnailing needs to decode them too
+	_ "image/jpeg"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+const catalogDriverName = "sqlite3_with_hamming"
+
+// registerCatalogDriver registers a sqlite3 driver variant with the hamming()
+// SQL function attached to every new connection, so queries can rank and
+// filter rows by perceptual distance (see phash.go). Registration panics on
+// duplicate Register calls, so it only runs once per process.
+var registerCatalogDriver = sync.OnceFunc(func() {
+	sql.Register(catalogDriverName, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			return conn.RegisterFunc("hamming", hamming, true)
+		},
+	})
+})
+
+const catalogSchema = `
+CREATE TABLE IF NOT EXISTS photos (
+	album TEXT NOT NULL DEFAULT 'default',
+	hash TEXT NOT NULL,
+	filename TEXT NOT NULL,
+	mtime DATETIME NOT NULL,
+	width INTEGER NOT NULL,
+	height INTEGER NOT NULL,
+	taken_at DATETIME,
+	phash INTEGER NOT NULL,
+	mime_type TEXT NOT NULL DEFAULT 'image/jpeg',
+	frame_count INTEGER NOT NULL DEFAULT 1,
+	PRIMARY KEY (album, hash)
+);
+
+CREATE TABLE IF NOT EXISTS users (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	username TEXT NOT NULL UNIQUE,
+	password_hash TEXT NOT NULL,
+	token TEXT NOT NULL UNIQUE
+);
+
+CREATE TABLE IF NOT EXISTS album_acls (
+	user_id INTEGER NOT NULL REFERENCES users(id),
+	album TEXT NOT NULL,
+	permission TEXT NOT NULL,
+	PRIMARY KEY (user_id, album)
+);
+`
+
+// catalogColumnMigrations adds columns that later versions of catalogSchema
+// introduced onto a photos table created by an earlier version of it. CREATE TABLE
+// IF NOT EXISTS only covers a catalog.db that doesn't exist yet; an upgrade
+// from an older build finds its photos table already there, so the new
+// columns have to be added explicitly or every query that reads or writes
+// them fails at runtime against the old schema.
+var catalogColumnMigrations = []struct {
+	column string
+	ddl    string
+}{
+	{"album", `ALTER TABLE photos ADD COLUMN album TEXT NOT NULL DEFAULT 'default'`},
+	{"mime_type", `ALTER TABLE photos ADD COLUMN mime_type TEXT NOT NULL DEFAULT 'image/jpeg'`},
+	{"frame_count", `ALTER TABLE photos ADD COLUMN frame_count INTEGER NOT NULL DEFAULT 1`},
+}
+
+// migrateCatalogColumns runs any catalogColumnMigrations the photos table is
+// still missing. It's safe to call on every startup: a column already
+// present is left alone.
+func migrateCatalogColumns(db *sql.DB) error {
+	existing, err := catalogColumns(db, "photos")
+	if err != nil {
+		return err
+	}
+
+	for _, m := range catalogColumnMigrations {
+		if existing[m.column] {
+			continue
+		}
+		if _, err := db.Exec(m.ddl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// catalogColumns returns the set of column names table currently has.
+func catalogColumns(db *sql.DB, table string) (map[string]bool, error) {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns := make(map[string]bool)
+	for rows.Next() {
+		var (
+			cid     int
+			name    string
+			ctype   string
+			notNull int
+			dflt    sql.NullString
+			pk      int
+		)
+		if err := rows.Scan(&cid, &name, &ctype, &notNull, &dflt, &pk); err != nil {
+			return nil, err
+		}
+		columns[name] = true
+	}
+	return columns, rows.Err()
+}
+
+// defaultAlbum is used when a request or upload does not name an album.
+const defaultAlbum = "default"
+
+// PhotoMetadata describes a single stored photo.
+type PhotoMetadata struct {
+	Album      string    `json:"album"`
+	Hash       string    `json:"hash"`
+	Filename   string    `json:"filename"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	Width      int       `json:"width"`
+	Height     int       `json:"height"`
+	TakenAt    time.Time `json:"taken_at,omitempty"`
+	PHash      int64     `json:"phash"`
+	MimeType   string    `json:"mime_type"`
+	FrameCount int       `json:"frame_count"`
+}
+
+// DuplicateError is returned by Add when a near-duplicate already exists in
+// the catalog. Handlers use it to answer with 409 Conflict and the
+// conflicting metadata so clients can choose to keep or discard the upload.
+type DuplicateError struct {
+	Existing PhotoMetadata
+	Distance int
+}
+
+func (e *DuplicateError) Error() string {
+	return fmt.Sprintf("near-duplicate of %s (distance %d)", e.Existing.Hash, e.Distance)
+}
+
+// DuplicateDistance is the Hamming distance at or below which an upload is
+// treated as a near-duplicate of an existing photo rather than a new one.
+const DuplicateDistance = 5
+
+// PhotoStorage is a SQLite-backed catalog of photos on disk. Perceptual
+// hashes let List and Add reason about near-duplicates without re-decoding
+// every file on every request.
+type PhotoStorage struct {
+	baseDir string
+	db      *sql.DB
+
+	subMu       sync.Mutex
+	subscribers map[string][]chan Event
+
+	thumbOnces sync.Map // map[string]*sync.Once, keyed by "hash:size"
+}
+
+func NewPhotoStorage(baseDir string) (*PhotoStorage, error) {
+	if err := os.MkdirAll(baseDir, 0755); err != nil {
+		return nil, err
+	}
+
+	registerCatalogDriver()
+
+	db, err := sql.Open(catalogDriverName, filepath.Join(baseDir, "catalog.db"))
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := db.Exec(catalogSchema); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if err := migrateCatalogColumns(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	ps := &PhotoStorage{baseDir: baseDir, db: db}
+
+	if err := ps.ensureShards(defaultAlbum); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if err := ps.migrateFlatLayout(defaultAlbum); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return ps, nil
+}
+
+// List returns photos in album. If similarTo is non-nil, results are
+// filtered to those within maxDistance of it and ordered by increasing
+// distance.
+func (ps *PhotoStorage) List(album string, similarTo *int64, maxDistance int) ([]PhotoMetadata, error) {
+	var rows *sql.Rows
+	var err error
+
+	if similarTo != nil {
+		rows, err = ps.db.Query(
+			`SELECT album, hash, filename, mtime, width, height, taken_at, phash, mime_type, frame_count FROM photos
+			 WHERE album = ? AND hamming(phash, ?) <= ?
+			 ORDER BY hamming(phash, ?)`,
+			album, *similarTo, maxDistance, *similarTo)
+	} else {
+		rows, err = ps.db.Query(`SELECT album, hash, filename, mtime, width, height, taken_at, phash, mime_type, frame_count FROM photos WHERE album = ?`, album)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := make([]PhotoMetadata, 0)
+	for rows.Next() {
+		var m PhotoMetadata
+		var takenAt sql.NullTime
+		if err := rows.Scan(&m.Album, &m.Hash, &m.Filename, &m.UpdatedAt, &m.Width, &m.Height, &takenAt, &m.PHash, &m.MimeType, &m.FrameCount); err != nil {
+			return nil, err
+		}
+		if takenAt.Valid {
+			m.TakenAt = takenAt.Time
+		}
+		list = append(list, m)
+	}
+	return list, rows.Err()
+}
+
+// Get returns the on-disk path for hash's photo in album. Stored photos can
+// be JPEG or GIF (see normalizeUpload), so this tries each possible
+// extension in turn; either way it's built directly from the hash, so this
+// never touches the catalog database. album and hash are validated here
+// rather than trusted from the caller, since both end up as path components.
+func (ps *PhotoStorage) Get(album, hash string) (string, error) {
+	if !ValidAlbum(album) || !ValidHash(hash) {
+		return "", os.ErrNotExist
+	}
+
+	for _, ext := range storedExts {
+		path := filepath.Join(ps.baseDir, album, shardName(hash), hash+ext)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", os.ErrNotExist
+}
+
+// nearestDuplicate returns the closest existing photo to phash within album
+// if it is within DuplicateDistance, or nil if there is no such photo.
+func (ps *PhotoStorage) nearestDuplicate(album string, phash int64) (*PhotoMetadata, int, error) {
+	var m PhotoMetadata
+	var takenAt sql.NullTime
+	var distance int
+	err := ps.db.QueryRow(
+		`SELECT album, hash, filename, mtime, width, height, taken_at, phash, mime_type, frame_count, hamming(phash, ?) AS dist
+		 FROM photos WHERE album = ? ORDER BY dist LIMIT 1`, phash, album,
+	).Scan(&m.Album, &m.Hash, &m.Filename, &m.UpdatedAt, &m.Width, &m.Height, &takenAt, &m.PHash, &m.MimeType, &m.FrameCount, &distance)
+	if err == sql.ErrNoRows {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	if distance > DuplicateDistance {
+		return nil, 0, nil
+	}
+	if takenAt.Valid {
+		m.TakenAt = takenAt.Time
+	}
+	return &m, distance, nil
+}
+
+// Add normalizes, hashes, and stores the uploaded photo under album (see
+// normalizeUpload for what "normalizes" means per format). If it is a
+// near-duplicate of an existing photo in that album, it is rejected with a
+// *DuplicateError instead of being written to disk. The hash is computed
+// over the normalized bytes, since that's what ends up on disk and what Get
+// derives a path from.
+func (ps *PhotoStorage) Add(album, filename string, reader io.Reader) (*PhotoMetadata, error) {
+	if !ValidAlbum(album) {
+		return nil, errInvalidAlbum
+	}
+
+	upload, err := normalizeUpload(reader)
+	if err != nil {
+		return nil, err
+	}
+	hash := sumBytes(upload.data)
+
+	phash := int64(computeDHash(upload.preview))
+	if dup, distance, err := ps.nearestDuplicate(album, phash); err != nil {
+		return nil, err
+	} else if dup != nil {
+		return nil, &DuplicateError{Existing: *dup, Distance: distance}
+	}
+
+	tempPath, err := ps.newStagingPath(album)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(tempPath, upload.data, 0644); err != nil {
+		os.Remove(tempPath)
+		return nil, err
+	}
+
+	finalPath := ps.shardedPath(album, hash, upload.mimeType)
+	if err := os.MkdirAll(filepath.Dir(finalPath), 0755); err != nil {
+		os.Remove(tempPath)
+		return nil, err
+	}
+	if err := os.Rename(tempPath, finalPath); err != nil {
+		os.Remove(tempPath)
+		return nil, err
+	}
+
+	info, err := os.Stat(finalPath)
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := upload.preview.Bounds()
+	meta := PhotoMetadata{
+		Album:      album,
+		Hash:       hash,
+		Filename:   filename,
+		UpdatedAt:  info.ModTime(),
+		Width:      bounds.Dx(),
+		Height:     bounds.Dy(),
+		TakenAt:    upload.takenAt,
+		PHash:      phash,
+		MimeType:   upload.mimeType,
+		FrameCount: upload.frameCount,
+	}
+
+	_, err = ps.db.Exec(
+		`INSERT INTO photos (album, hash, filename, mtime, width, height, taken_at, phash, mime_type, frame_count) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		meta.Album, meta.Hash, meta.Filename, meta.UpdatedAt, meta.Width, meta.Height, nullableTime(meta.TakenAt), meta.PHash, meta.MimeType, meta.FrameCount)
+	if err != nil {
+		return nil, err
+	}
+
+	ps.publish(Event{
+		Type:       EventAdded,
+		Album:      album,
+		Hash:       meta.Hash,
+		Filename:   meta.Filename,
+		MimeType:   meta.MimeType,
+		FrameCount: meta.FrameCount,
+	})
+
+	return &meta, nil
+}
+
+func (ps *PhotoStorage) Delete(album, hash string) error {
+	path, err := ps.Get(album, hash)
+	if err != nil {
+		return err
+	}
+
+	var filename string
+	if err := ps.db.QueryRow(`SELECT filename FROM photos WHERE album = ? AND hash = ?`, album, hash).Scan(&filename); err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil {
+		return err
+	}
+
+	if _, err := ps.db.Exec(`DELETE FROM photos WHERE album = ? AND hash = ?`, album, hash); err != nil {
+		return err
+	}
+
+	ps.publish(Event{Type: EventDeleted, Album: album, Hash: hash, Filename: filename})
+	return nil
+}
+
+func nullableTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t
+}
@@ -0,0 +1,68 @@
+// events.go
+package store
+
+// Event describes a single photo being added to or removed from an album.
+// MimeType and FrameCount are only meaningful for EventAdded; a subscriber
+// uses them to decide whether a photo is animated before requesting a
+// thumbnail (thumbnailing always re-encodes to a still JPEG, see
+// thumbnail.go).
+type Event struct {
+	Type       string `json:"type"` // "added" or "deleted"
+	Album      string `json:"album"`
+	Hash       string `json:"hash"`
+	Filename   string `json:"filename"`
+	MimeType   string `json:"mime_type,omitempty"`
+	FrameCount int    `json:"frame_count,omitempty"`
+}
+
+const (
+	EventAdded   = "added"
+	EventDeleted = "deleted"
+)
+
+// subscriberBuffer is how many events a slow subscriber can lag behind
+// before publish starts skipping events for it rather than blocking
+// Add/Delete. The subscriber itself stays registered.
+const subscriberBuffer = 16
+
+// Subscribe registers a channel that receives every Event published for
+// album. Call the returned function to unsubscribe and close the channel.
+func (ps *PhotoStorage) Subscribe(album string) (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+
+	ps.subMu.Lock()
+	if ps.subscribers == nil {
+		ps.subscribers = make(map[string][]chan Event)
+	}
+	ps.subscribers[album] = append(ps.subscribers[album], ch)
+	ps.subMu.Unlock()
+
+	unsubscribe := func() {
+		ps.subMu.Lock()
+		defer ps.subMu.Unlock()
+		subs := ps.subscribers[album]
+		for i, c := range subs {
+			if c == ch {
+				ps.subscribers[album] = append(subs[:i], subs[i+1:]...)
+				close(ch)
+				break
+			}
+		}
+	}
+
+	return ch, unsubscribe
+}
+
+// publish fans event out to every subscriber of its album. A subscriber
+// whose buffer is full is skipped rather than blocking the caller.
+func (ps *PhotoStorage) publish(event Event) {
+	ps.subMu.Lock()
+	defer ps.subMu.Unlock()
+
+	for _, ch := range ps.subscribers[event.Album] {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
@@ -0,0 +1,27 @@
+// exif.go
+package store
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/rwcarlsen/goexif/exif"
+)
+
+// readDateTimeOriginal returns the EXIF DateTimeOriginal tag from raw, the
+// original upload bytes, or the zero time if there's no EXIF data or the tag
+// is absent. This has to run against the original upload: a still that gets
+// re-encoded to JPEG (see normalizeUpload) no longer carries its source
+// EXIF segment once stored.
+func readDateTimeOriginal(raw []byte) time.Time {
+	x, err := exif.Decode(bytes.NewReader(raw))
+	if err != nil {
+		return time.Time{}
+	}
+
+	t, err := x.DateTime()
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
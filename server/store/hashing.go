@@ -0,0 +1,16 @@
+// hashing.go
+package store
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// sumBytes returns the hex-encoded SHA-256 digest of data. Add hashes the
+// normalized upload rather than the raw one, since the hash has to match
+// what's actually written to disk (see normalizeUpload), so the upload is
+// already fully in memory by the time this runs.
+func sumBytes(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,144 @@
+// ingest.go
+package store
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/image/webp"
+)
+
+// HEICDecoder decodes a HEIC/HEIF image into Go's image.Image. The standard
+// library and golang.org/x/image have no pure-Go HEIC decoder, so support is
+// opt-in: a binary that wants it links a libheif binding and registers it
+// with RegisterHEICDecoder during init.
+type HEICDecoder interface {
+	Decode(r io.Reader) (image.Image, error)
+}
+
+var heicDecoder HEICDecoder
+
+// RegisterHEICDecoder installs the decoder normalizeUpload uses for HEIC
+// uploads. Without one, HEIC uploads are rejected with an error naming the
+// missing format instead of silently failing to decode.
+func RegisterHEICDecoder(d HEICDecoder) {
+	heicDecoder = d
+}
+
+// jpegBufferPool reuses re-encode buffers across uploads so a burst of
+// normalizations doesn't pay for a fresh allocation each time.
+var jpegBufferPool = sync.Pool{
+	New: func() interface{} { return new(bytes.Buffer) },
+}
+
+// normalizedUpload is the canonical form an upload is stored in, plus the
+// metadata Add needs to record about it.
+type normalizedUpload struct {
+	data       []byte
+	mimeType   string
+	frameCount int
+	preview    image.Image // representative frame, for phash and dimensions
+	takenAt    time.Time   // from the original upload's EXIF data, before any re-encode strips it
+}
+
+// normalizeUpload sniffs an uploaded image's format and converts it to this
+// catalog's canonical stored form: a still image (JPEG, PNG, WebP, or HEIC)
+// becomes a quality-90 JPEG with EXIF orientation baked in, while a GIF,
+// animated or single-frame, is kept as-is, since re-encoding it risks subtly
+// breaking its palette or per-frame timing for no benefit we need.
+//
+// The JPEG output is baseline: Go's standard jpeg encoder has no progressive
+// mode.
+func normalizeUpload(r io.Reader) (*normalizedUpload, error) {
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Read before any re-encode, since encoding a fresh JPEG from a decoded
+	// image.Image drops the source's EXIF segment entirely.
+	takenAt := readDateTimeOriginal(raw)
+
+	mimeType := http.DetectContentType(raw)
+	switch {
+	case mimeType == "image/gif":
+		frames, err := gif.DecodeAll(bytes.NewReader(raw))
+		if err != nil {
+			return nil, err
+		}
+		return &normalizedUpload{
+			data:       raw,
+			mimeType:   "image/gif",
+			frameCount: len(frames.Image),
+			preview:    frames.Image[0],
+			takenAt:    takenAt,
+		}, nil
+
+	case mimeType == "image/png" || mimeType == "image/jpeg":
+		img, _, err := image.Decode(bytes.NewReader(raw))
+		if err != nil {
+			return nil, err
+		}
+		return encodeJPEG(applyOrientation(img, raw), takenAt)
+
+	case mimeType == "image/webp":
+		img, err := webp.Decode(bytes.NewReader(raw))
+		if err != nil {
+			return nil, err
+		}
+		return encodeJPEG(applyOrientation(img, raw), takenAt)
+
+	case looksLikeHEIC(raw):
+		if heicDecoder == nil {
+			return nil, fmt.Errorf("HEIC uploads require a registered HEICDecoder")
+		}
+		img, err := heicDecoder.Decode(bytes.NewReader(raw))
+		if err != nil {
+			return nil, err
+		}
+		return encodeJPEG(applyOrientation(img, raw), takenAt)
+
+	default:
+		return nil, fmt.Errorf("unsupported upload format: %s", mimeType)
+	}
+}
+
+// encodeJPEG re-encodes img as a quality-90 JPEG using a pooled buffer, so
+// normalizing a batch of uploads doesn't allocate a fresh encode buffer per
+// photo.
+func encodeJPEG(img image.Image, takenAt time.Time) (*normalizedUpload, error) {
+	buf := jpegBufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	defer jpegBufferPool.Put(buf)
+
+	if err := jpeg.Encode(buf, img, &jpeg.Options{Quality: 90}); err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, buf.Len())
+	copy(data, buf.Bytes())
+
+	return &normalizedUpload{data: data, mimeType: "image/jpeg", frameCount: 1, preview: img, takenAt: takenAt}, nil
+}
+
+// looksLikeHEIC reports whether raw starts with an ISO base media "ftyp" box
+// carrying a HEIC/HEIF brand. http.DetectContentType doesn't recognize HEIC,
+// so it falls to this narrower sniff.
+func looksLikeHEIC(raw []byte) bool {
+	if len(raw) < 12 || string(raw[4:8]) != "ftyp" {
+		return false
+	}
+	switch string(raw[8:12]) {
+	case "heic", "heix", "heim", "heis", "hevc", "hevm", "hevs", "mif1", "msf1":
+		return true
+	}
+	return false
+}
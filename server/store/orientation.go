@@ -0,0 +1,107 @@
+// orientation.go
+package store
+
+import (
+	"bytes"
+	"image"
+
+	"github.com/rwcarlsen/goexif/exif"
+)
+
+// applyOrientation rotates/flips img according to the EXIF Orientation tag
+// found in raw, so a stored photo is always upright regardless of how the
+// camera was held. img is returned unchanged if raw has no EXIF data, no
+// Orientation tag, or the tag is 1 (already upright).
+func applyOrientation(img image.Image, raw []byte) image.Image {
+	x, err := exif.Decode(bytes.NewReader(raw))
+	if err != nil {
+		return img
+	}
+	tag, err := x.Get(exif.Orientation)
+	if err != nil {
+		return img
+	}
+	orientation, err := tag.Int(0)
+	if err != nil {
+		return img
+	}
+	return rotateForOrientation(img, orientation)
+}
+
+// rotateForOrientation applies the transform for one of the 8 EXIF
+// orientation values (the TIFF/EXIF spec's numbering).
+func rotateForOrientation(img image.Image, orientation int) image.Image {
+	switch orientation {
+	case 2:
+		return flipHorizontal(img)
+	case 3:
+		return rotate180(img)
+	case 4:
+		return flipVertical(img)
+	case 5:
+		return flipHorizontal(rotate90(img))
+	case 6:
+		return rotate90(img)
+	case 7:
+		return flipHorizontal(rotate270(img))
+	case 8:
+		return rotate270(img)
+	default:
+		return img
+	}
+}
+
+func rotate90(img image.Image) image.Image {
+	b := img.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, b.Dy(), b.Dx()))
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			dst.Set(b.Max.Y-1-y, x, img.At(x, y))
+		}
+	}
+	return dst
+}
+
+func rotate180(img image.Image) image.Image {
+	b := img.Bounds()
+	dst := image.NewNRGBA(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			dst.Set(b.Max.X-1-x, b.Max.Y-1-y, img.At(x, y))
+		}
+	}
+	return dst
+}
+
+func rotate270(img image.Image) image.Image {
+	b := img.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, b.Dy(), b.Dx()))
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			dst.Set(y, b.Max.X-1-x, img.At(x, y))
+		}
+	}
+	return dst
+}
+
+func flipHorizontal(img image.Image) image.Image {
+	b := img.Bounds()
+	dst := image.NewNRGBA(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			dst.Set(b.Max.X-1-x, y, img.At(x, y))
+		}
+	}
+	return dst
+}
+
+func flipVertical(img image.Image) image.Image {
+	b := img.Bounds()
+	dst := image.NewNRGBA(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			dst.Set(x, b.Max.Y-1-y, img.At(x, y))
+		}
+	}
+	return dst
+}
@@ -0,0 +1,67 @@
+package store
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// grayAt reads back img's pixel as a gray value regardless of the concrete
+// image type rotateForOrientation produced (image.Gray for orientation 1,
+// image.NRGBA for every rotation/flip).
+func grayAt(img image.Image, x, y int) uint8 {
+	return color.GrayModel.Convert(img.At(x, y)).(color.Gray).Y
+}
+
+// gridOf reads img (width w, height h) row-major into a flat slice for easy
+// comparison against an expected literal.
+func gridOf(img image.Image, w, h int) []uint8 {
+	grid := make([]uint8, 0, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			grid = append(grid, grayAt(img, x, y))
+		}
+	}
+	return grid
+}
+
+// TestRotateForOrientation covers all 8 EXIF orientation values against a
+// 3x2 source image with a distinct value per pixel, so a transposition or
+// off-by-one in any one case shows up as a mismatched grid.
+func TestRotateForOrientation(t *testing.T) {
+	const srcW, srcH = 3, 2
+	src := image.NewGray(image.Rect(0, 0, srcW, srcH))
+	// 0 1 2
+	// 3 4 5
+	for i, v := range []uint8{0, 1, 2, 3, 4, 5} {
+		src.SetGray(i%srcW, i/srcW, color.Gray{Y: v})
+	}
+
+	tests := []struct {
+		orientation int
+		w, h        int
+		want        []uint8
+	}{
+		{1, srcW, srcH, []uint8{0, 1, 2, 3, 4, 5}}, // identity
+		{2, srcW, srcH, []uint8{2, 1, 0, 5, 4, 3}}, // flip horizontal
+		{3, srcW, srcH, []uint8{5, 4, 3, 2, 1, 0}}, // rotate 180
+		{4, srcW, srcH, []uint8{3, 4, 5, 0, 1, 2}}, // flip vertical
+		{5, srcH, srcW, []uint8{0, 3, 1, 4, 2, 5}}, // transpose
+		{6, srcH, srcW, []uint8{3, 0, 4, 1, 5, 2}}, // rotate 90
+		{7, srcH, srcW, []uint8{5, 2, 4, 1, 3, 0}}, // transverse
+		{8, srcH, srcW, []uint8{2, 5, 1, 4, 0, 3}}, // rotate 270
+	}
+
+	for _, tt := range tests {
+		got := gridOf(rotateForOrientation(src, tt.orientation), tt.w, tt.h)
+		if len(got) != len(tt.want) {
+			t.Fatalf("orientation %d: got %d pixels, want %d", tt.orientation, len(got), len(tt.want))
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("orientation %d: grid = %v, want %v", tt.orientation, got, tt.want)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,46 @@
+// phash.go
+package store
+
+import (
+	"image"
+	"math/bits"
+
+	"golang.org/x/image/draw"
+)
+
+// dHash dimensions: one extra column so we can diff each row left-to-right.
+const (
+	dhashWidth  = 9
+	dhashHeight = 8
+)
+
+// computeDHash returns a 64-bit difference hash for img. The image is resized
+// to 9x8 grayscale and bit i is set iff pixel[i] is brighter than pixel[i+1]
+// along its row. The result is robust to scaling, mild color changes, and
+// JPEG recompression, so near-identical images land within a small Hamming
+// distance of each other.
+func computeDHash(img image.Image) uint64 {
+	gray := image.NewGray(image.Rect(0, 0, dhashWidth, dhashHeight))
+	draw.CatmullRom.Scale(gray, gray.Bounds(), img, img.Bounds(), draw.Over, nil)
+
+	var hash uint64
+	bit := 0
+	for y := 0; y < dhashHeight; y++ {
+		for x := 0; x < dhashWidth-1; x++ {
+			left := gray.GrayAt(x, y).Y
+			right := gray.GrayAt(x+1, y).Y
+			if left > right {
+				hash |= 1 << uint(bit)
+			}
+			bit++
+		}
+	}
+	return hash
+}
+
+// hamming returns the number of differing bits between a and b, i.e. the
+// popcount of a XOR b. It is registered as a SQL function so the catalog can
+// rank and filter rows by perceptual similarity directly in the query.
+func hamming(a, b int64) int64 {
+	return int64(bits.OnesCount64(uint64(a) ^ uint64(b)))
+}
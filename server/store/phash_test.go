@@ -0,0 +1,61 @@
+package store
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestHamming(t *testing.T) {
+	tests := []struct {
+		name     string
+		a, b     int64
+		distance int64
+	}{
+		{"identical", 0x0F0F0F0F0F0F0F0F, 0x0F0F0F0F0F0F0F0F, 0},
+		{"full complement", 0, -1, 64}, // -1 as int64 is all 64 bits set
+		{"single bit", 0b0001, 0b0000, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hamming(tt.a, tt.b); got != tt.distance {
+				t.Errorf("hamming(%#x, %#x) = %d, want %d", tt.a, tt.b, got, tt.distance)
+			}
+		})
+	}
+}
+
+func TestComputeDHash(t *testing.T) {
+	const w, h = 90, 80
+
+	// A strictly descending left-to-right gradient should set every bit
+	// (each pixel is brighter than its right neighbor); the mirrored
+	// ascending gradient should set none.
+	descending := image.NewGray(image.Rect(0, 0, w, h))
+	ascending := image.NewGray(image.Rect(0, 0, w, h))
+	flat := image.NewGray(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			v := uint8(255 - x*2)
+			descending.SetGray(x, y, color.Gray{Y: v})
+			ascending.SetGray(x, y, color.Gray{Y: 255 - v})
+			flat.SetGray(x, y, color.Gray{Y: 128})
+		}
+	}
+
+	if got := computeDHash(descending); got != ^uint64(0) {
+		t.Errorf("computeDHash(descending) = %#016x, want all bits set", got)
+	}
+	if got := computeDHash(ascending); got != 0 {
+		t.Errorf("computeDHash(ascending) = %#016x, want 0", got)
+	}
+	// A flat image has no brightness difference between any neighbors, so
+	// it hashes to 0 too — dHash can't distinguish a uniform image's color.
+	if got := computeDHash(flat); got != 0 {
+		t.Errorf("computeDHash(flat) = %#016x, want 0", got)
+	}
+
+	if dist := hamming(int64(computeDHash(descending)), int64(computeDHash(ascending))); dist != 64 {
+		t.Errorf("hamming distance between opposite gradients = %d, want 64", dist)
+	}
+}
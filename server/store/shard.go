@@ -0,0 +1,113 @@
+// shard.go
+package store
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// storedExts lists the extensions a photo may be stored under: normalized
+// non-GIF stills are JPEG, and GIFs, animated or single-frame, are kept
+// as-is (see normalizeUpload). Get tries each in turn so it can find a photo's path
+// without a catalog lookup.
+var storedExts = []string{".jpg", ".gif"}
+
+// extForMimeType returns the on-disk extension for a normalized upload's
+// MIME type.
+func extForMimeType(mimeType string) string {
+	if mimeType == "image/gif" {
+		return ".gif"
+	}
+	return ".jpg"
+}
+
+// shardName is the two-hex-digit subdirectory a hash's file lives under,
+// keeping any one directory from holding more than ~1/256th of an album.
+func shardName(hash string) string {
+	if len(hash) < 2 {
+		return "00"
+	}
+	return hash[:2]
+}
+
+// shardedPath returns the on-disk path for hash's photo within album, given
+// the MIME type it was stored as. The path is derived purely from the hash
+// and mimeType, so callers never need a catalog lookup to build it. Callers
+// must validate album and hash (see ValidAlbum, ValidHash) before calling,
+// since both are used as path components here.
+func (ps *PhotoStorage) shardedPath(album, hash, mimeType string) string {
+	return filepath.Join(ps.baseDir, album, shardName(hash), hash+extForMimeType(mimeType))
+}
+
+// ensureShards pre-creates the 256 shard subdirectories for album.
+// NewPhotoStorage runs it for the default album only; Add still calls
+// MkdirAll on the destination shard, which creates shards for other albums.
+func (ps *PhotoStorage) ensureShards(album string) error {
+	for i := 0; i < 256; i++ {
+		dir := filepath.Join(ps.baseDir, album, fmt.Sprintf("%02x", i))
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newStagingPath returns a path for a temporary upload, namespaced away from
+// any shard directory so a crash mid-upload never leaves a partial file
+// under its destination shard.
+func (ps *PhotoStorage) newStagingPath(album string) (string, error) {
+	stagingDir := filepath.Join(ps.baseDir, album, "tmp")
+	if err := os.MkdirAll(stagingDir, 0755); err != nil {
+		return "", err
+	}
+
+	suffix := make([]byte, 16)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", err
+	}
+	return filepath.Join(stagingDir, "tmp-"+hex.EncodeToString(suffix)), nil
+}
+
+// migrateFlatLayout moves any photo rows in album still living directly
+// under the album directory (the pre-sharding layout) into their shard,
+// renamed to their hash. It is a one-shot fixup run at startup, safe to call
+// repeatedly: photos already in their shard are left alone.
+func (ps *PhotoStorage) migrateFlatLayout(album string) error {
+	rows, err := ps.db.Query(`SELECT hash, filename, mime_type FROM photos WHERE album = ?`, album)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	type legacyRow struct{ hash, filename, mimeType string }
+	var legacy []legacyRow
+	for rows.Next() {
+		var r legacyRow
+		if err := rows.Scan(&r.hash, &r.filename, &r.mimeType); err != nil {
+			return err
+		}
+		legacy = append(legacy, r)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, r := range legacy {
+		oldPath := filepath.Join(ps.baseDir, album, r.filename)
+		if _, err := os.Stat(oldPath); err != nil {
+			continue // already migrated, or filename no longer matches the original layout
+		}
+
+		newPath := ps.shardedPath(album, r.hash, r.mimeType)
+		if err := os.MkdirAll(filepath.Dir(newPath), 0755); err != nil {
+			return err
+		}
+		if err := os.Rename(oldPath, newPath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestShardName(t *testing.T) {
+	tests := []struct {
+		hash string
+		want string
+	}{
+		{strings.Repeat("a", 64), "aa"},
+		{"b", "00"},  // shorter than 2 chars falls back to "00"
+		{"", "00"},
+	}
+	for _, tt := range tests {
+		if got := shardName(tt.hash); got != tt.want {
+			t.Errorf("shardName(%q) = %q, want %q", tt.hash, got, tt.want)
+		}
+	}
+}
+
+func TestShardedPath(t *testing.T) {
+	ps := &PhotoStorage{baseDir: "/data"}
+	hash := strings.Repeat("c", 64)
+
+	got := ps.shardedPath("vacation", hash, "image/jpeg")
+	want := filepath.Join("/data", "vacation", "cc", hash+".jpg")
+	if got != want {
+		t.Errorf("shardedPath(jpeg) = %q, want %q", got, want)
+	}
+
+	got = ps.shardedPath("vacation", hash, "image/gif")
+	want = filepath.Join("/data", "vacation", "cc", hash+".gif")
+	if got != want {
+		t.Errorf("shardedPath(gif) = %q, want %q", got, want)
+	}
+}
+
+func TestMigrateFlatLayout(t *testing.T) {
+	dir := t.TempDir()
+	ps, err := NewPhotoStorage(dir)
+	if err != nil {
+		t.Fatalf("NewPhotoStorage: %v", err)
+	}
+
+	const album = defaultAlbum
+	hash := strings.Repeat("d", 64)
+	flatPath := filepath.Join(dir, album, "photo.jpg")
+	if err := os.WriteFile(flatPath, []byte("fake jpeg"), 0644); err != nil {
+		t.Fatalf("seed flat file: %v", err)
+	}
+	if _, err := ps.db.Exec(
+		`INSERT INTO photos (album, hash, filename, mtime, width, height, phash, mime_type, frame_count)
+		 VALUES (?, ?, ?, CURRENT_TIMESTAMP, 1, 1, 0, ?, 1)`,
+		album, hash, "photo.jpg", "image/jpeg",
+	); err != nil {
+		t.Fatalf("seed row: %v", err)
+	}
+
+	if err := ps.migrateFlatLayout(album); err != nil {
+		t.Fatalf("migrateFlatLayout: %v", err)
+	}
+
+	if _, err := os.Stat(flatPath); !os.IsNotExist(err) {
+		t.Errorf("flat path still exists, want it moved into its shard: %v", err)
+	}
+	wantPath := ps.shardedPath(album, hash, "image/jpeg")
+	if _, err := os.Stat(wantPath); err != nil {
+		t.Errorf("expected photo at %s: %v", wantPath, err)
+	}
+
+	// Calling it again is a no-op: nothing left in the flat layout to move.
+	if err := ps.migrateFlatLayout(album); err != nil {
+		t.Fatalf("second migrateFlatLayout: %v", err)
+	}
+}
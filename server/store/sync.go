@@ -0,0 +1,131 @@
+// sync.go
+package store
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"os"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// SyncFrameHeader precedes each photo's bytes in a WriteSyncDelta response.
+type SyncFrameHeader struct {
+	Hash       string `json:"hash"`
+	Filename   string `json:"filename"`
+	MimeType   string `json:"mime_type"`
+	FrameCount int    `json:"frame_count"`
+	Length     int64  `json:"length"`
+}
+
+// WriteSyncDelta writes a single zstd-compressed stream to w containing
+// every photo in album not named in known, followed by the hashes in known
+// that no longer exist in album. Each photo is a 4-byte little-endian
+// length, a JSON-encoded SyncFrameHeader of that length, and then Length
+// bytes of photo data; a zero length marks the end of photos, followed by a
+// 4-byte length and a JSON array of hashes to delete.
+//
+// This replaces the list-then-GET-per-photo pattern PhotoSync otherwise
+// uses with a single round trip, at the cost of the caller having to parse
+// this bespoke framing instead of plain JSON.
+func (ps *PhotoStorage) WriteSyncDelta(w io.Writer, album string, known []string) error {
+	have := make(map[string]bool, len(known))
+	for _, h := range known {
+		have[h] = true
+	}
+
+	all, err := ps.List(album, nil, 0)
+	if err != nil {
+		return err
+	}
+
+	remoteHashes := make(map[string]bool, len(all))
+	var toSend []PhotoMetadata
+	for _, m := range all {
+		remoteHashes[m.Hash] = true
+		if !have[m.Hash] {
+			toSend = append(toSend, m)
+		}
+	}
+
+	var toDelete []string
+	for _, h := range known {
+		if !remoteHashes[h] {
+			toDelete = append(toDelete, h)
+		}
+	}
+
+	enc, err := zstd.NewWriter(w)
+	if err != nil {
+		return err
+	}
+	defer enc.Close()
+
+	for _, m := range toSend {
+		if err := writePhotoFrame(enc, ps, album, m); err != nil {
+			return err
+		}
+	}
+
+	if err := writeFrameLen(enc, 0); err != nil {
+		return err
+	}
+
+	deleteBytes, err := json.Marshal(toDelete)
+	if err != nil {
+		return err
+	}
+	return writeFrame(enc, deleteBytes)
+}
+
+func writePhotoFrame(w io.Writer, ps *PhotoStorage, album string, m PhotoMetadata) error {
+	path, err := ps.Get(album, m.Hash)
+	if err != nil {
+		return nil // photo vanished between List and now; skip it, the next sync will retry
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	header := SyncFrameHeader{
+		Hash:       m.Hash,
+		Filename:   m.Filename,
+		MimeType:   m.MimeType,
+		FrameCount: m.FrameCount,
+		Length:     info.Size(),
+	}
+	headerBytes, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+	if err := writeFrame(w, headerBytes); err != nil {
+		return err
+	}
+
+	_, err = io.Copy(w, f)
+	return err
+}
+
+func writeFrame(w io.Writer, data []byte) error {
+	if err := writeFrameLen(w, uint32(len(data))); err != nil {
+		return err
+	}
+	_, err := w.Write(data)
+	return err
+}
+
+func writeFrameLen(w io.Writer, n uint32) error {
+	var buf [4]byte
+	binary.LittleEndian.PutUint32(buf[:], n)
+	_, err := w.Write(buf[:])
+	return err
+}
@@ -0,0 +1,116 @@
+// thumbnail.go
+package store
+
+import (
+	"fmt"
+	"image"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"golang.org/x/image/draw"
+)
+
+// thumbSizes are the power-of-two long-edge sizes thumbnails are generated
+// at. A requested width snaps up to the smallest size that still fits it.
+var thumbSizes = []int{256, 512, 1024, 2048}
+
+func snapThumbSize(width int) int {
+	for _, s := range thumbSizes {
+		if width <= s {
+			return s
+		}
+	}
+	return thumbSizes[len(thumbSizes)-1]
+}
+
+// Thumbnail returns the path to a cached JPEG thumbnail of hash's photo with
+// its long edge at width (snapped up to a stock size, see snapThumbSize), generating it
+// on first request. Concurrent requests for the same (hash, size) share a
+// single decode via thumbOnces so a burst of clients hitting a cold cache
+// only pays for it once.
+func (ps *PhotoStorage) Thumbnail(album, hash string, width int) (string, error) {
+	srcPath, err := ps.Get(album, hash)
+	if err != nil {
+		return "", err
+	}
+
+	size := snapThumbSize(width)
+	thumbPath := ps.thumbPath(hash, size)
+	key := fmt.Sprintf("%s:%d", hash, size)
+
+	onceIface, _ := ps.thumbOnces.LoadOrStore(key, &sync.Once{})
+	once := onceIface.(*sync.Once)
+
+	var genErr error
+	once.Do(func() {
+		genErr = generateThumbnail(srcPath, thumbPath, size)
+		if genErr != nil {
+			ps.thumbOnces.Delete(key)
+		}
+	})
+
+	if _, err := os.Stat(thumbPath); err != nil {
+		if genErr != nil {
+			return "", genErr
+		}
+		return "", err
+	}
+	return thumbPath, nil
+}
+
+func (ps *PhotoStorage) thumbPath(hash string, size int) string {
+	prefix := hash
+	if len(prefix) > 2 {
+		prefix = prefix[:2]
+	}
+	return filepath.Join(ps.baseDir, "thumbs", prefix, fmt.Sprintf("%s_%d.jpg", hash, size))
+}
+
+// generateThumbnail decodes src, scales it so its long edge is size pixels,
+// and writes it to dst as a JPEG. The write is tmp-then-rename so a crash
+// mid-encode never leaves a corrupt thumbnail in place.
+func generateThumbnail(src, dst string, size int) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	img, _, err := image.Decode(f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	scale := float64(size) / float64(w)
+	if h > w {
+		scale = float64(size) / float64(h)
+	}
+	newW, newH := int(float64(w)*scale), int(float64(h)*scale)
+
+	thumb := image.NewRGBA(image.Rect(0, 0, newW, newH))
+	draw.CatmullRom.Scale(thumb, thumb.Bounds(), img, bounds, draw.Over, nil)
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	tmp := dst + ".tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if err := jpeg.Encode(out, thumb, &jpeg.Options{Quality: 90}); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	return os.Rename(tmp, dst)
+}
@@ -0,0 +1,29 @@
+// validate.go
+package store
+
+import (
+	"errors"
+	"regexp"
+)
+
+var errInvalidAlbum = errors.New("invalid album name")
+
+// albumPattern is the allowlist album names must match so they can be used
+// directly as a path component (see shardedPath, Get, Delete, Add) without
+// risking directory traversal or escaping baseDir.
+var albumPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
+
+// hashPattern matches the hex-encoded SHA-256 digests sumBytes produces.
+var hashPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// ValidAlbum reports whether album is safe to use as a single path
+// component: no "/", "..", or characters outside the allowlist.
+func ValidAlbum(album string) bool {
+	return albumPattern.MatchString(album)
+}
+
+// ValidHash reports whether hash has the shape of a digest produced by
+// sumBytes, so it's safe to use as a path component.
+func ValidHash(hash string) bool {
+	return hashPattern.MatchString(hash)
+}
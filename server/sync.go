@@ -0,0 +1,30 @@
+// sync.go
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+// handleSync answers the delta-sync endpoint: the client posts the hashes
+// it already has, and gets back a zstd-compressed stream of everything it's
+// missing plus the hashes it should delete. See store.WriteSyncDelta for the
+// wire format.
+func (s *Server) handleSync(w http.ResponseWriter, r *http.Request, album string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var known []string
+	if err := json.NewDecoder(r.Body).Decode(&known); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-goframe-syncdelta+zstd")
+	if err := s.photos.WriteSyncDelta(w, album, known); err != nil {
+		log.Printf("sync delta for album %s failed: %v", album, err)
+	}
+}